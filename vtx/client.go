@@ -0,0 +1,366 @@
+package vtx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ClientConfig configures a Client.
+type ClientConfig struct {
+	// DroneAddr is the drone's IP address. The zero value defaults to
+	// 192.168.0.1, the address the drone's own access point hands out to
+	// itself, so a Client can be pointed at a fake server in tests.
+	DroneAddr net.IP
+
+	// Proxy is a SOCKS5 or plain TCP proxy URL (e.g. "socks5://host:1080")
+	// to dial DroneAddr through, for controllers that aren't themselves on
+	// the drone's Wi-Fi. If empty, the dialer installed by SetDialer is
+	// used, falling back to the ALL_PROXY environment variable and then a
+	// direct connection.
+	Proxy string
+}
+
+var defaultDroneAddr = net.IPv4(192, 168, 0, 1)
+
+// Client is a session to one of the drone's two TCP command ports (7060 for
+// video, 8060 for everything else). A single reader goroutine demultiplexes
+// responses by command type across any number of concurrent callers,
+// replacing the old Req/Res/Action functions, which only let one goroutine
+// safely use a connection at a time and panicked on an unexpected command.
+type Client struct {
+	cfg    ClientConfig
+	port   int
+	dialer proxy.ContextDialer // nil means dial directly, sourced from getLocalIP
+
+	mu          sync.Mutex
+	conn        net.Conn
+	closing     bool
+	waiters     map[uint32][]chan waiterResult
+	subs        map[uint32][]chan []byte
+	errs        chan error
+	reconnected chan struct{}
+}
+
+type waiterResult struct {
+	payload []byte
+	err     error
+}
+
+// Errors returned by Client in place of the panic Res used to raise on an
+// unexpected command type.
+var (
+	// ErrConnLost is returned to an in-flight Do call, and to Send, when
+	// the connection to the drone is lost.
+	ErrConnLost = errors.New("vtx: connection to drone lost")
+	// ErrTimeout is returned by Do when ctx is done before a response
+	// arrives.
+	ErrTimeout = errors.New("vtx: timed out waiting for response")
+)
+
+// ErrUnexpectedCmd reports that a frame arrived for a command Do wasn't
+// waiting for and nothing had Subscribed to.
+type ErrUnexpectedCmd struct {
+	Want, Got uint32
+}
+
+func (e *ErrUnexpectedCmd) Error() string {
+	return fmt.Sprintf("vtx: unexpected response command; want %#x; got %#x", e.Want, e.Got)
+}
+
+// NewClient dials port (7060 or 8060) on the drone and starts the
+// connection's demultiplexing reader goroutine and internal keepalive.
+// cfg's zero value reproduces the previous hardcoded behaviour of dialing
+// 192.168.0.1.
+func NewClient(port int, cfg ClientConfig) (*Client, error) {
+	if cfg.DroneAddr == nil {
+		cfg.DroneAddr = defaultDroneAddr
+	}
+	dialer, err := resolveDialer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		cfg:         cfg,
+		port:        port,
+		dialer:      dialer,
+		waiters:     make(map[uint32][]chan waiterResult),
+		subs:        make(map[uint32][]chan []byte),
+		errs:        make(chan error, 8),
+		reconnected: make(chan struct{}, 1),
+	}
+	if err := c.reconnect(); err != nil {
+		return nil, err
+	}
+	go c.readLoop()
+	go c.keepAliveLoop()
+	return c, nil
+}
+
+// Do sends cmd with payload and blocks until a response tagged with the
+// same cmd arrives, ctx is done, or the connection is lost.
+func (c *Client) Do(ctx context.Context, cmd uint32, payload interface{}) ([]byte, error) {
+	ch := make(chan waiterResult, 1)
+	c.mu.Lock()
+	c.waiters[cmd] = append(c.waiters[cmd], ch)
+	c.mu.Unlock()
+
+	if err := c.Send(cmd, payload); err != nil {
+		c.deregisterWaiter(cmd, ch)
+		return nil, err
+	}
+
+	select {
+	case res := <-ch:
+		return res.payload, res.err
+	case <-ctx.Done():
+		// Deregister ch ourselves: readLoop has no idea this call gave up,
+		// and without this it sits at the head of cmd's FIFO queue forever
+		// - leaking the channel, and stealing whatever frame of cmd type
+		// arrives next out from under Errs()/a later Do call.
+		c.deregisterWaiter(cmd, ch)
+		return nil, ErrTimeout
+	}
+}
+
+// deregisterWaiter removes ch from cmd's waiter queue, if it's still
+// there; readLoop may have already popped and delivered to it.
+func (c *Client) deregisterWaiter(cmd uint32, ch chan waiterResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	waiters := c.waiters[cmd]
+	for i, w := range waiters {
+		if w == ch {
+			c.waiters[cmd] = append(waiters[:i], waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Subscribe registers interest in frames tagged with cmd, for streaming
+// commands such as the live video feed (command 0x0101, following a
+// 0x0002 restart) or keepalive-driven notifications. The returned cancel
+// func unregisters and closes the channel; call it once the caller is
+// done with the stream. Subscriptions survive automatic reconnects.
+func (c *Client) Subscribe(cmd uint32) (<-chan []byte, func()) {
+	ch := make(chan []byte, 8)
+	c.mu.Lock()
+	c.subs[cmd] = append(c.subs[cmd], ch)
+	c.mu.Unlock()
+
+	cancel := func() {
+		c.mu.Lock()
+		subs := c.subs[cmd]
+		for i, s := range subs {
+			if s == ch {
+				c.subs[cmd] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		c.mu.Unlock()
+		close(ch)
+	}
+	return ch, cancel
+}
+
+// Errs returns the channel frames are reported on when they arrive for a
+// command nothing is waiting for or Subscribed to, as an
+// *ErrUnexpectedCmd; unclaimed keepAliveCmd replies are dropped without
+// a report. It's best-effort: if nothing reads it, further
+// reports are dropped rather than blocking the reader goroutine.
+func (c *Client) Errs() <-chan error {
+	return c.errs
+}
+
+// Reconnected reports whenever readLoop re-establishes the drone connection
+// after a drop, so a caller whose state is scoped to a single TCP session
+// (e.g. the webrtc package re-issuing the command that starts the video
+// stream on that connection) knows to redo that setup. It's best-effort
+// like Errs: a reconnect while the channel is already full is dropped
+// rather than blocking the reader goroutine.
+func (c *Client) Reconnected() <-chan struct{} {
+	return c.reconnected
+}
+
+// Send issues cmd without waiting for a response. Use Do instead if the
+// drone answers with the same command type.
+func (c *Client) Send(cmd uint32, payload interface{}) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return ErrConnLost
+	}
+	req := NewLeweiCmd(cmd)
+	req.AddPayload(payload)
+	if err := send(conn, req); err != nil {
+		return ErrConnLost
+	}
+	return nil
+}
+
+// Close shuts down the client's reader and keepalive goroutines and the
+// underlying connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.closing = true
+	conn := c.conn
+	c.mu.Unlock()
+	if conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+// readLoop is the client's single reader goroutine. It decodes frames and
+// demultiplexes each to whichever Do waiter or Subscribe channel is
+// registered for that frame's command type, reconnecting with exponential
+// backoff on any read error.
+func (c *Client) readLoop() {
+	backoff := time.Second
+	for {
+		c.mu.Lock()
+		closing := c.closing
+		conn := c.conn
+		c.mu.Unlock()
+		if closing {
+			return
+		}
+		if conn == nil {
+			time.Sleep(backoff)
+			if err := c.reconnect(); err != nil {
+				if backoff < 30*time.Second {
+					backoff *= 2
+				}
+				continue
+			}
+			backoff = time.Second
+			select {
+			case c.reconnected <- struct{}{}:
+			default:
+			}
+			continue
+		}
+
+		resp, err := recv(conn)
+		if err != nil {
+			c.dropConn(conn, ErrConnLost)
+			continue
+		}
+
+		cmd := resp.headerGet(cmdI)
+		payload := resp.payload.Bytes()
+
+		c.mu.Lock()
+		delivered := false
+		if waiters := c.waiters[cmd]; len(waiters) > 0 {
+			waiters[0] <- waiterResult{payload: payload}
+			c.waiters[cmd] = waiters[1:]
+			delivered = true
+		}
+		for _, sub := range c.subs[cmd] {
+			select {
+			case sub <- payload:
+			default: // slow subscriber; drop rather than block the reader
+			}
+			delivered = true
+		}
+		c.mu.Unlock()
+
+		if !delivered && cmd != keepAliveCmd {
+			select {
+			case c.errs <- &ErrUnexpectedCmd{Got: cmd}:
+			default:
+			}
+		}
+	}
+}
+
+// dropConn fails every in-flight Do call with err and clears the
+// connection so readLoop reconnects. Subscribe channels are left
+// registered so they keep receiving once reconnected.
+func (c *Client) dropConn(bad net.Conn, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != bad {
+		return // already reconnected by another goroutine
+	}
+	c.conn = nil
+	for cmd, waiters := range c.waiters {
+		for _, w := range waiters {
+			w <- waiterResult{err: err}
+		}
+		delete(c.waiters, cmd)
+	}
+}
+
+// reconnect dials a fresh connection to the drone, replacing c.conn.
+//
+// With a dialer set (via ClientConfig.Proxy, SetDialer or ALL_PROXY) the
+// drone is reached through it, by address alone; the "smallest IP in
+// 192.168.0.0/24" heuristic in getLocalIP only makes sense for a direct
+// connection from the drone's own Wi-Fi, so it's skipped entirely.
+func (c *Client) reconnect() error {
+	addr := net.JoinHostPort(c.cfg.DroneAddr.String(), fmt.Sprint(c.port))
+
+	if c.dialer != nil {
+		conn, err := c.dialer.DialContext(context.Background(), "tcp", addr)
+		if err != nil {
+			return fmt.Errorf("vtx: dial %s via proxy: %w", addr, err)
+		}
+		c.installConn(conn)
+		return nil
+	}
+
+	raddr := &net.TCPAddr{IP: c.cfg.DroneAddr, Port: c.port}
+	var laddr *net.TCPAddr
+	if !c.cfg.DroneAddr.IsLoopback() {
+		// A loopback DroneAddr (a local fake server or Replayer) is dialed
+		// without binding a 192.168.0.0/24 source address.
+		laddr = &net.TCPAddr{IP: getLocalIP()}
+	}
+	conn, err := net.DialTCP("tcp4", laddr, raddr)
+	if err != nil {
+		return fmt.Errorf("vtx: dial %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	c.installConn(conn)
+	return nil
+}
+
+// installConn stores conn as c.conn, unless Close has already been called
+// while the dial was in flight - in which case conn would otherwise never
+// be closed, leaking a live connection to the drone past the point the
+// caller believed Close had torn everything down.
+func (c *Client) installConn(conn net.Conn) {
+	c.mu.Lock()
+	if c.closing {
+		c.mu.Unlock()
+		conn.Close()
+		return
+	}
+	c.conn = conn
+	c.mu.Unlock()
+}
+
+// keepAliveLoop periodically sends keepAliveCmd to hold the connection
+// open, the way the package-level keepAlive helper used to.
+func (c *Client) keepAliveLoop() {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		c.mu.Lock()
+		closing := c.closing
+		c.mu.Unlock()
+		if closing {
+			return
+		}
+		c.Send(keepAliveCmd, nil)
+	}
+}
@@ -0,0 +1,125 @@
+package vtx
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeServer is a minimal stand-in for the drone's command port, so Client
+// can be exercised against known frames without a real drone.
+type fakeServer struct {
+	ln net.Listener
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	return &fakeServer{ln: ln}
+}
+
+// proxyURL returns a "tcp://" proxy URL pointing straight at the server, so
+// tests can dial it without relying on getLocalIP's 192.168.0.0/24
+// heuristic, which doesn't hold in a test environment.
+func (s *fakeServer) proxyURL() string {
+	return "tcp://" + s.ln.Addr().String()
+}
+
+func (s *fakeServer) accept(t *testing.T) net.Conn {
+	t.Helper()
+	conn, err := s.ln.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	return conn
+}
+
+func TestClientDoRoundTrip(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.ln.Close()
+
+	go func() {
+		conn := srv.accept(t)
+		defer conn.Close()
+		req, err := recv(conn)
+		if err != nil {
+			t.Errorf("server recv: %v", err)
+			return
+		}
+		if got := req.headerGet(cmdI); got != checkVideoCmd {
+			t.Errorf("server got cmd %#x, want %#x", got, checkVideoCmd)
+		}
+		resp := NewLeweiCmd(checkVideoCmd)
+		resp.AddPayload([]uint32{42})
+		if err := send(conn, resp); err != nil {
+			t.Errorf("server send: %v", err)
+		}
+	}()
+
+	c, err := NewClient(0, ClientConfig{Proxy: srv.proxyURL()})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	payload, err := c.Do(ctx, checkVideoCmd, nil)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if got := binary.LittleEndian.Uint32(payload); got != 42 {
+		t.Errorf("payload = %d, want 42", got)
+	}
+}
+
+// TestClientSubscribeSurvivesReconnect checks that a Subscribe channel keeps
+// receiving frames after the drone connection drops and Client transparently
+// reconnects, the behaviour the readLoop doc comment promises.
+func TestClientSubscribeSurvivesReconnect(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.ln.Close()
+
+	sendFrame := func(conn net.Conn, value uint32) {
+		f := NewLeweiCmd(videoFileCmd)
+		f.AddPayload([]uint32{value})
+		send(conn, f)
+	}
+
+	go func() {
+		conn1 := srv.accept(t)
+		time.Sleep(100 * time.Millisecond) // give the client time to Subscribe
+		sendFrame(conn1, 1)
+		conn1.Close() // simulate a dropped connection
+
+		conn2 := srv.accept(t) // Client should reconnect on its own
+		defer conn2.Close()
+		sendFrame(conn2, 2)
+		time.Sleep(200 * time.Millisecond)
+	}()
+
+	c, err := NewClient(0, ClientConfig{Proxy: srv.proxyURL()})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	defer c.Close()
+
+	frames, cancel := c.Subscribe(videoFileCmd)
+	defer cancel()
+
+	for i, want := range []uint32{1, 2} {
+		select {
+		case payload := <-frames:
+			if got := binary.LittleEndian.Uint32(payload); got != want {
+				t.Errorf("frame %d = %d, want %d", i, got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for frame %d", i)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package vtx
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+
+	"golang.org/x/net/proxy"
+)
+
+func init() {
+	proxy.RegisterDialerType("tcp", newTCPForwardDialer)
+}
+
+// newTCPForwardDialer implements the "tcp" proxy scheme: golang.org/x/net/proxy
+// only ships socks5/socks5h/direct, but a plain TCP relay - one end of an
+// `ssh -L` port forward, or a socat relay - lands directly on the drone, so
+// dialing it means connecting straight to u.Host rather than performing any
+// handshake.
+func newTCPForwardDialer(u *url.URL, forward proxy.Dialer) (proxy.Dialer, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("vtx: tcp proxy URL %q has no host:port", u)
+	}
+	return &tcpForwardDialer{addr: u.Host, forward: forward}, nil
+}
+
+// tcpForwardDialer dials its configured addr regardless of the address
+// passed to Dial/DialContext, since that address is already the drone's
+// end of the forward.
+type tcpForwardDialer struct {
+	addr    string
+	forward proxy.Dialer
+}
+
+func (d *tcpForwardDialer) Dial(network, _ string) (net.Conn, error) {
+	return d.forward.Dial(network, d.addr)
+}
+
+func (d *tcpForwardDialer) DialContext(ctx context.Context, network, _ string) (net.Conn, error) {
+	if cd, ok := d.forward.(proxy.ContextDialer); ok {
+		return cd.DialContext(ctx, network, d.addr)
+	}
+	return d.forward.Dial(network, d.addr)
+}
+
+// dialerMu guards the package-level default dialer installed by SetDialer.
+var (
+	dialerMu      sync.Mutex
+	defaultDialer proxy.ContextDialer
+)
+
+// SetDialer installs d as the dialer every Client created afterwards uses to
+// reach the drone, unless a Client's own ClientConfig.Proxy says otherwise;
+// a Client already created keeps the dialer it started with. This lets a
+// controller that isn't itself on the drone's Wi-Fi - connected instead
+// over an LTE modem, a VPN, or an SSH `-D` tunnel to something that is on
+// the drone's Wi-Fi - still fly it. Passing nil restores the default:
+// ALL_PROXY if set, else a direct dial.
+func SetDialer(d proxy.ContextDialer) {
+	dialerMu.Lock()
+	defaultDialer = d
+	dialerMu.Unlock()
+}
+
+func getDefaultDialer() proxy.ContextDialer {
+	dialerMu.Lock()
+	defer dialerMu.Unlock()
+	return defaultDialer
+}
+
+// dialerFromURL builds a proxy.ContextDialer for a SOCKS5 or plain TCP
+// proxy URL, e.g. "socks5://127.0.0.1:1080" or "tcp://10.0.0.2:1234".
+func dialerFromURL(rawURL string) (proxy.ContextDialer, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("vtx: parse proxy URL %q: %w", rawURL, err)
+	}
+	d, err := proxy.FromURL(u, proxy.Direct)
+	if err != nil {
+		return nil, fmt.Errorf("vtx: proxy %q: %w", rawURL, err)
+	}
+	cd, ok := d.(proxy.ContextDialer)
+	if !ok {
+		return nil, fmt.Errorf("vtx: proxy %q doesn't support dialing with a context", rawURL)
+	}
+	return cd, nil
+}
+
+// resolveDialer picks the dialer a Client should use to reach the drone:
+// cfg.Proxy if set, else the package-level default installed by SetDialer,
+// else the ALL_PROXY environment variable, else nil for a direct dial.
+func resolveDialer(cfg ClientConfig) (proxy.ContextDialer, error) {
+	if cfg.Proxy != "" {
+		return dialerFromURL(cfg.Proxy)
+	}
+	if d := getDefaultDialer(); d != nil {
+		return d, nil
+	}
+	if env := os.Getenv("ALL_PROXY"); env != "" {
+		return dialerFromURL(env)
+	}
+	return nil, nil
+}
@@ -0,0 +1,184 @@
+package vtx
+
+import (
+	"container/list"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sync"
+)
+
+// DownloadCacheSize is the default size, in bytes, of the packet cache kept
+// by Download so a dropped connection can resume a video download without
+// re-fetching chunks already written out. It can be changed before calling
+// Download.
+var DownloadCacheSize = 4 << 20 // ~4 MiB
+
+// maxStallChunks bounds how many chunks Download will buffer ahead of the
+// next one it needs before giving up waiting for it and re-issuing
+// downloadVideoCmd to request a resume; the drone has no way to re-request a single missing
+// chunk, only to resume a download from a given offset.
+const maxStallChunks = 64
+
+// chunk is one videoFileCmd payload as received off the wire: a little
+// endian uint32 sequence number, the chunk's data, and a trailing little
+// endian uint32 CRC32 (IEEE) of that data.
+type chunk struct {
+	seq  uint32
+	data []byte
+}
+
+// packetCache is a size-bounded, LRU-evicted store of received chunks,
+// keyed by their sequence number, modeled on the retransmission caches
+// used by RTP stacks.
+type packetCache struct {
+	mu      sync.Mutex
+	maxSize int
+	size    int
+	order   *list.List
+	byIndex map[uint32]*list.Element
+}
+
+func newPacketCache(maxSize int) *packetCache {
+	return &packetCache{
+		maxSize: maxSize,
+		order:   list.New(),
+		byIndex: make(map[uint32]*list.Element),
+	}
+}
+
+func (c *packetCache) put(ch chunk) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.byIndex[ch.seq]; ok {
+		c.size += len(ch.data) - len(e.Value.(chunk).data)
+		e.Value = ch
+		c.order.MoveToFront(e)
+	} else {
+		c.byIndex[ch.seq] = c.order.PushFront(ch)
+		c.size += len(ch.data)
+	}
+
+	for c.size > c.maxSize && c.order.Len() > 1 {
+		oldest := c.order.Back()
+		evicted := oldest.Value.(chunk)
+		c.order.Remove(oldest)
+		delete(c.byIndex, evicted.seq)
+		c.size -= len(evicted.data)
+	}
+}
+
+func (c *packetCache) get(seq uint32) (chunk, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.byIndex[seq]
+	if !ok {
+		return chunk{}, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(chunk), true
+}
+
+func (c *packetCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Download streams the recorded video identified by videoID to w over this
+// client, replacing the previous fire-and-forget Action call for
+// downloadVideoCmd.
+//
+// It issues downloadVideoCmd and streams the videoFileCmd chunks that come
+// back into w while indexing them in a bounded packet cache; on a gap it
+// can't fill from that cache (including one opened by the client silently
+// reconnecting underneath it) it re-issues downloadVideoCmd asking the
+// drone to resume at the last contiguous offset. Download is cancellable
+// via ctx.
+func (c *Client) Download(ctx context.Context, videoID uint32, w io.Writer) (bytesWritten int64, err error) {
+	cache := newPacketCache(DownloadCacheSize)
+	frames, cancel := c.Subscribe(videoFileCmd)
+	defer cancel()
+
+	var nextSeq uint32
+	if err := c.Send(downloadVideoCmd, []uint32{videoID, nextSeq}); err != nil {
+		return 0, err
+	}
+
+	stalled := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return bytesWritten, ctx.Err()
+
+		case <-c.Reconnected():
+			// A fresh connection carries no chunks until the drone is asked
+			// again, so resume from the last contiguous chunk.
+			stalled = 0
+			if err := c.Send(downloadVideoCmd, []uint32{videoID, nextSeq}); err != nil {
+				return bytesWritten, err
+			}
+
+		case payload, ok := <-frames:
+			if !ok {
+				return bytesWritten, ErrConnLost
+			}
+
+			ch, ok := decodeChunk(payload)
+			if !ok {
+				continue // failed CRC check; drop it, a gap will trigger a resume
+			}
+			if len(ch.data) == 0 {
+				return bytesWritten, nil // end of file
+			}
+			cache.put(ch)
+
+			progressed := false
+			for {
+				cached, ok := cache.get(nextSeq)
+				if !ok {
+					break
+				}
+				n, werr := w.Write(cached.data)
+				bytesWritten += int64(n)
+				if werr != nil {
+					return bytesWritten, werr
+				}
+				nextSeq++
+				progressed = true
+			}
+
+			if progressed {
+				stalled = 0
+				continue
+			}
+			stalled++
+			if stalled >= maxStallChunks || cache.len() >= maxStallChunks {
+				stalled = 0
+				if err := c.Send(downloadVideoCmd, []uint32{videoID, nextSeq}); err != nil {
+					return bytesWritten, err
+				}
+			}
+		}
+	}
+}
+
+// decodeChunk parses a videoFileCmd payload into its sequence number and
+// data, validating the trailing CRC32. It reports ok=false for a payload
+// too short to contain the trailer or one that fails the checksum.
+func decodeChunk(payload []byte) (ch chunk, ok bool) {
+	const trailerLen = 4
+	if len(payload) < 4+trailerLen {
+		return chunk{}, false
+	}
+	seq := binary.LittleEndian.Uint32(payload)
+	data := payload[4 : len(payload)-trailerLen]
+	wantCRC := binary.LittleEndian.Uint32(payload[len(payload)-trailerLen:])
+	if crc32.ChecksumIEEE(data) != wantCRC {
+		return chunk{}, false
+	}
+	return chunk{seq: seq, data: data}, true
+}
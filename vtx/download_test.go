@@ -0,0 +1,104 @@
+package vtx
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"net"
+	"testing"
+	"time"
+)
+
+func makeChunkPayload(seq uint32, data []byte) []byte {
+	buf := make([]byte, 4+len(data)+4)
+	binary.LittleEndian.PutUint32(buf, seq)
+	copy(buf[4:], data)
+	binary.LittleEndian.PutUint32(buf[4+len(data):], crc32.ChecksumIEEE(data))
+	return buf
+}
+
+// TestDownloadResume checks that Download, faced with a persistent gap it
+// can never fill from its packet cache, re-issues downloadVideoCmd asking
+// the drone to resume from the last contiguous offset instead of stalling
+// forever.
+func TestDownloadResume(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.ln.Close()
+
+	const videoID = 7
+	readDownloadReq := func(conn net.Conn) (gotVideoID, gotNextSeq uint32) {
+		for {
+			req, err := recv(conn)
+			if err != nil {
+				t.Fatalf("server recv: %v", err)
+			}
+			if got := req.headerGet(cmdI); got == keepAliveCmd {
+				continue // Client's keepAliveLoop ticks independently of Download
+			} else if got != downloadVideoCmd {
+				t.Fatalf("server got cmd %#x, want %#x", got, downloadVideoCmd)
+			}
+			payload := req.payload.Bytes()
+			return binary.LittleEndian.Uint32(payload[0:4]), binary.LittleEndian.Uint32(payload[4:8])
+		}
+	}
+	sendChunk := func(conn net.Conn, seq uint32, data []byte) {
+		f := NewLeweiCmd(videoFileCmd)
+		f.AddPayload(makeChunkPayload(seq, data))
+		if err := send(conn, f); err != nil {
+			t.Fatalf("server send: %v", err)
+		}
+	}
+
+	go func() {
+		conn := srv.accept(t)
+		defer conn.Close()
+
+		gotVideoID, gotNextSeq := readDownloadReq(conn)
+		if gotVideoID != videoID || gotNextSeq != 0 {
+			t.Errorf("initial request = (%d, %d), want (%d, 0)", gotVideoID, gotNextSeq, videoID)
+		}
+
+		// A persistent gap: seq 0 never arrives, so nextSeq never advances
+		// and Download's stall counter (or its packet cache, keyed by these
+		// distinct sequence numbers) climbs to maxStallChunks. A few extra
+		// chunks and a small pacing delay cover for Subscribe's bounded
+		// channel silently dropping one under a instantaneous burst. The
+		// sequence numbers are well clear of the real data below so they
+		// can't satisfy it once the resumed download starts.
+		const fillerSeqBase = 10000
+		for i := uint32(0); i < maxStallChunks+16; i++ {
+			sendChunk(conn, fillerSeqBase+i, []byte("x"))
+			time.Sleep(time.Millisecond)
+		}
+
+		gotVideoID, gotNextSeq = readDownloadReq(conn)
+		if gotVideoID != videoID || gotNextSeq != 0 {
+			t.Errorf("resume request = (%d, %d), want (%d, 0)", gotVideoID, gotNextSeq, videoID)
+		}
+
+		sendChunk(conn, 0, []byte("hello"))
+		sendChunk(conn, 1, nil) // empty data signals end of file
+	}()
+
+	c, err := NewClient(0, ClientConfig{Proxy: srv.proxyURL()})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var w bytes.Buffer
+	n, err := c.Download(ctx, videoID, &w)
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if got := w.String(); got != "hello" {
+		t.Errorf("downloaded data = %q, want %q", got, "hello")
+	}
+	if n != int64(len("hello")) {
+		t.Errorf("bytesWritten = %d, want %d", n, len("hello"))
+	}
+}
@@ -0,0 +1,259 @@
+package vtx
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Direction distinguishes a frame sent to the drone from one received from
+// it in a Recorder capture.
+type Direction uint8
+
+const (
+	DirSent Direction = iota // controller -> drone
+	DirRecv                  // drone -> controller
+)
+
+// Recorder wraps a live net.Conn and writes every framed LeweiCmd that
+// passes through it, in either direction, to an on-disk capture. The
+// format is simple length-prefixed records:
+//
+//	int64 nanosSinceStart | uint8 direction | uint32 headerLen | uint32 payloadLen | header | payload
+//
+// Attaching a capture to an issue lets a contributor reproduce a bug with
+// Replayer instead of the real drone.
+type Recorder struct {
+	net.Conn
+
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	sent  frameParser
+	recv  frameParser
+}
+
+// NewRecorder wraps conn so every frame sent or received through it is
+// also written to w.
+func NewRecorder(conn net.Conn, w io.Writer) *Recorder {
+	return &Recorder{Conn: conn, w: w, start: time.Now()}
+}
+
+// Write sends b to the wrapped connection and records every complete
+// LeweiCmd frame it contains as DirSent.
+func (r *Recorder) Write(b []byte) (int, error) {
+	n, err := r.Conn.Write(b)
+	if n > 0 {
+		r.record(DirSent, &r.sent, b[:n])
+	}
+	return n, err
+}
+
+// Read reads from the wrapped connection and records every complete
+// LeweiCmd frame it contains as DirRecv.
+func (r *Recorder) Read(b []byte) (int, error) {
+	n, err := r.Conn.Read(b)
+	if n > 0 {
+		r.record(DirRecv, &r.recv, b[:n])
+	}
+	return n, err
+}
+
+func (r *Recorder) record(dir Direction, parser *frameParser, data []byte) {
+	for _, f := range parser.feed(data) {
+		r.writeRecord(dir, f.header, f.payload)
+	}
+}
+
+func (r *Recorder) writeRecord(dir Direction, header, payload []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	binary.Write(r.w, binary.LittleEndian, int64(time.Since(r.start)))
+	binary.Write(r.w, binary.LittleEndian, uint8(dir))
+	binary.Write(r.w, binary.LittleEndian, uint32(len(header)))
+	binary.Write(r.w, binary.LittleEndian, uint32(len(payload)))
+	r.w.Write(header)
+	r.w.Write(payload)
+}
+
+// rawFrame is one fully reassembled LeweiCmd header+payload pair.
+type rawFrame struct {
+	header  []byte
+	payload []byte
+}
+
+// frameParser reassembles LeweiCmd frames out of however the caller
+// happens to chunk its Reads and Writes, the same way recv reassembles
+// them off a real connection.
+type frameParser struct {
+	buf []byte
+}
+
+func (p *frameParser) feed(b []byte) []rawFrame {
+	p.buf = append(p.buf, b...)
+
+	var frames []rawFrame
+	for {
+		if len(p.buf) < headerSize {
+			break
+		}
+		payloadLen := int(binary.LittleEndian.Uint32(p.buf[10+lenI*4:]))
+		total := headerSize + payloadLen
+		if len(p.buf) < total {
+			break
+		}
+		frames = append(frames, rawFrame{
+			header:  append([]byte(nil), p.buf[:headerSize]...),
+			payload: append([]byte(nil), p.buf[headerSize:total]...),
+		})
+		p.buf = p.buf[total:]
+	}
+	return frames
+}
+
+// record is one entry read back from a Recorder capture.
+type record struct {
+	at      time.Duration
+	dir     Direction
+	header  []byte
+	payload []byte
+}
+
+func readRecord(r io.Reader) (record, error) {
+	var nanos int64
+	var dir uint8
+	var headerLen, payloadLen uint32
+
+	if err := binary.Read(r, binary.LittleEndian, &nanos); err != nil {
+		return record{}, err // a clean io.EOF here means end of capture
+	}
+	if err := binary.Read(r, binary.LittleEndian, &dir); err != nil {
+		return record{}, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &headerLen); err != nil {
+		return record{}, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &payloadLen); err != nil {
+		return record{}, err
+	}
+
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return record{}, err
+	}
+	payload := make([]byte, payloadLen)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return record{}, err
+	}
+
+	return record{at: time.Duration(nanos), dir: Direction(dir), header: header, payload: payload}, nil
+}
+
+func headerCmd(header []byte) uint32 {
+	return binary.LittleEndian.Uint32(header[10+cmdI*4:])
+}
+
+// Replayer serves a Recorder capture from a local TCP listener, faithfully
+// reproducing the original inter-packet timing (scaled by the speed given to
+// NewReplayer), so the
+// rest of the drone controller - the multiplexer, the WebRTC gateway,
+// Download - can be exercised without a drone in the loop.
+type Replayer struct {
+	ln      net.Listener
+	records []record
+	speed   float64
+}
+
+// NewReplayer reads every record written by a Recorder from r and starts
+// listening on a local TCP port. speed scales the replay rate; 0 or
+// negative means realtime (1x).
+func NewReplayer(r io.Reader, speed float64) (*Replayer, error) {
+	if speed <= 0 {
+		speed = 1
+	}
+
+	var records []record
+	for {
+		rec, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	rep := &Replayer{ln: ln, records: records, speed: speed}
+	go rep.acceptLoop()
+	return rep, nil
+}
+
+// Addr returns the address the replayer is listening on. A test points
+// ClientConfig.DroneAddr at its IP and passes its port to NewClient.
+func (rep *Replayer) Addr() *net.TCPAddr {
+	return rep.ln.Addr().(*net.TCPAddr)
+}
+
+// Close stops accepting new connections.
+func (rep *Replayer) Close() error {
+	return rep.ln.Close()
+}
+
+func (rep *Replayer) acceptLoop() {
+	for {
+		conn, err := rep.ln.Accept()
+		if err != nil {
+			return
+		}
+		go rep.serve(conn)
+	}
+}
+
+func (rep *Replayer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	// keepAliveCmd is answered live instead of from the tape, so a
+	// replayed capture stays alive for as long as the client wants it to,
+	// independent of how long the original recording ran.
+	go func() {
+		for {
+			f, err := recv(conn)
+			if err != nil {
+				return
+			}
+			if f.headerGet(cmdI) == keepAliveCmd {
+				if send(conn, NewLeweiCmd(keepAliveCmd)) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	start := time.Now()
+	for _, rec := range rep.records {
+		if rec.dir != DirRecv {
+			continue // only frames the real drone sent are replayed
+		}
+		if headerCmd(rec.header) == keepAliveCmd {
+			continue // handled live, above
+		}
+
+		if wait := time.Duration(float64(rec.at)/rep.speed) - time.Since(start); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		frame := LeweiCmd{header: rec.header}
+		frame.payload.Write(rec.payload)
+		if send(conn, frame) != nil {
+			return
+		}
+	}
+}
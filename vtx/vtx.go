@@ -2,6 +2,8 @@
 //
 // TCP port 7060 is for live video stream data (also for downloading/replaying captured videos)
 // TCP port 8060 is for the rest - start/stop video capturing, taking pohoto, listing videos on sd card etc.
+//
+// Client is a session to one of those ports; see client.go.
 package vtx
 
 import (
@@ -11,7 +13,6 @@ import (
 	"io"
 	"net"
 	"reflect"
-	"time"
 	"unsafe"
 )
 
@@ -20,6 +21,10 @@ const (
 	on  = 1
 )
 
+// headerSize is the length, in bytes, of a LeweiCmd header: the
+// "lewei_cmd\0" string plus 9 little endian uint32 numbers.
+const headerSize = 46
+
 // Header of commands consists of "lewei_cmd\0" string and 9 uint32 numbers (little endian)
 // only first and fourth number has known meaning so far
 const (
@@ -61,7 +66,7 @@ type LeweiCmd struct {
 
 // NewLeweiCmd will create new LeweiCmd with correct header initialized and given action set
 func NewLeweiCmd(action uint32) LeweiCmd {
-	header := make([]byte, 46)
+	header := make([]byte, headerSize)
 	copy(header, "lewei_cmd\x00")
 	cmd := LeweiCmd{header: header}
 	cmd.headerSet(cmdI, action)
@@ -108,41 +113,6 @@ func (c *LeweiCmd) String() (str string) {
 	return str
 }
 
-func newConn(port int) (*net.TCPConn, func()) {
-	raddr := &net.TCPAddr{IP: net.IPv4(192, 168, 0, 1), Port: port} // IP of drone
-	laddr := &net.TCPAddr{IP: getLocalIP()}                         // auto port
-	conn, err := net.DialTCP("tcp4", laddr, raddr)
-	if err != nil {
-		fmt.Printf("%v\n%v\n", fmt.Errorf("Cant't create connection, are you on right wifi?"), err)
-		return nil, nil
-	}
-	conn.SetDeadline(time.Time{})
-	// conn.SetDeadline(time.Now().Add(time.Second * 50))
-	closeConn := keepAlive(conn)
-	return conn, closeConn
-}
-
-// KeepAlive will keep conn alive until function returned by it is called
-func keepAlive(conn *net.TCPConn) func() {
-	ticker := time.NewTicker(time.Second * 2)
-	stop := make(chan bool)
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				Req(keepAliveCmd, nil, conn)
-			case <-stop:
-				ticker.Stop()
-				conn.Close()
-				return
-			}
-		}
-	}()
-	return func() {
-		stop <- true
-	}
-}
-
 // getLocalIP gets smallest ip in 192.168.0.* which exists in the system
 func getLocalIP() net.IP {
 	bestIP := net.IPv4(192, 168, 0, 255)
@@ -158,19 +128,20 @@ func getLocalIP() net.IP {
 	return bestIP
 }
 
-func send(conn *net.TCPConn, cmd LeweiCmd) error {
+func send(conn net.Conn, cmd LeweiCmd) error {
 	_, err := conn.Write(cmd.header)
 	conn.Write(cmd.payload.Bytes())
 	return err
 }
 
-func recv(conn *net.TCPConn) (LeweiCmd, error) {
+func recv(conn net.Conn) (LeweiCmd, error) {
 	cmd := NewLeweiCmd(0)
-	n, err := conn.Read(cmd.header)
-	if n != len(cmd.header) {
-		println("not whole header", len(cmd.header), n) // correct port?
-	}
-	if err != nil {
+	// ReadFull, not Read: a short read here desyncs framing (payloadLen
+	// comes out garbage and io.CopyN below wedges waiting for bytes that
+	// may never come) - and since Client shares this connection's reader
+	// goroutine across every in-flight Do/Subscribe, that wedges the whole
+	// client rather than just one call.
+	if _, err := io.ReadFull(conn, cmd.header); err != nil {
 		return cmd, err
 	}
 	payloadLen := cmd.headerGet(lenI)
@@ -187,15 +158,6 @@ func recv(conn *net.TCPConn) (LeweiCmd, error) {
 	return cmd, nil
 }
 
-func portByCmd(cmd uint32) int {
-	switch cmd {
-	case playVideoCmd, downloadVideoCmd, keepAliveCmd:
-		return 7060
-	default:
-		return 8060
-	}
-}
-
 func byteToUint32(arr []byte) []uint32 {
 	arr = arr[:]
 	header := *(*reflect.SliceHeader)(unsafe.Pointer(&arr))
@@ -203,51 +165,3 @@ func byteToUint32(arr []byte) []uint32 {
 	header.Cap /= 4
 	return *(*[]uint32)(unsafe.Pointer(&header))
 }
-
-// Action combines together Req and Res functions
-//
-// it will make request of type given by cmd and call callback function with response payload in byte slice
-func Action(cmd uint32, payload interface{}, callback func([]byte)) {
-	conn, closeConn := newConn(portByCmd(cmd))
-	if conn == nil {
-		return
-	}
-	defer closeConn()
-	Req(cmd, payload, conn)
-	data := Res(cmd, conn)
-
-	if callback != nil {
-		callback(data)
-	}
-}
-
-// Req will create and send request to TCP conn
-//
-// Use Action instead, if you expect response with same cmd type
-func Req(cmd uint32, payload interface{}, conn *net.TCPConn) {
-	// send request
-	req := NewLeweiCmd(cmd)
-	req.AddPayload(payload)
-	send(conn, req)
-}
-
-// Res will obtain response from TCP conn
-//
-// Use Action instead, if tis is response for requsest of same cmd type
-func Res(cmd uint32, conn *net.TCPConn) (payload []byte) {
-	// load payload:
-start:
-	resp, _ := recv(conn)
-
-	// check return type
-	recvCmd := resp.headerGet(cmdI)
-	if recvCmd != cmd {
-		if recvCmd == keepAliveCmd {
-			// ignore keepAlive response and start over
-			goto start
-		} else {
-			panic(fmt.Errorf("invalid response command type; exp %v; got %v", cmd, recvCmd))
-		}
-	}
-	return resp.payload.Bytes()
-}
@@ -0,0 +1,46 @@
+package webrtc
+
+import "time"
+
+// jitterEstimator tracks inter-arrival jitter (RFC 3550 §6.4.1) for the
+// incoming frame stream and derives a playout delay recommendation from it.
+type jitterEstimator struct {
+	lastArrival time.Time
+	lastRTP     uint32
+	estimate    float64 // smoothed jitter, in RTP timestamp units
+}
+
+func newJitterEstimator() *jitterEstimator {
+	return &jitterEstimator{}
+}
+
+// Update feeds one more arrival into the estimator and returns the current
+// jitter estimate in RTP timestamp units (90 kHz for H.264).
+func (j *jitterEstimator) Update(rtpTimestamp uint32, arrival time.Time) float64 {
+	if !j.lastArrival.IsZero() {
+		sentDiff := float64(int32(rtpTimestamp - j.lastRTP))
+		arrivalDiff := float64(arrival.Sub(j.lastArrival)) / float64(time.Second) * clockRate
+		d := arrivalDiff - sentDiff
+		if d < 0 {
+			d = -d
+		}
+		j.estimate += (d - j.estimate) / 16 // RFC 3550 smoothing factor
+	}
+	j.lastArrival = arrival
+	j.lastRTP = rtpTimestamp
+	return j.estimate
+}
+
+// PlayoutDelay returns the initial playout delay to buffer before
+// presenting frames, sized from the current jitter estimate with a floor
+// so a near-zero jitter reading doesn't starve the jitter buffer outright.
+func (j *jitterEstimator) PlayoutDelay() time.Duration {
+	d := time.Duration(j.estimate / clockRate * 3 * float64(time.Second))
+	if d < 20*time.Millisecond {
+		return 20 * time.Millisecond
+	}
+	if d > 200*time.Millisecond {
+		return 200 * time.Millisecond
+	}
+	return d
+}
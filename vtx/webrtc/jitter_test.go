@@ -0,0 +1,46 @@
+package webrtc
+
+import (
+	"testing"
+	"time"
+)
+
+// TestPlayoutDelayScalesWithEstimate guards against PlayoutDelay truncating
+// its Duration conversion before scaling by time.Second, which made it
+// return the fixed 20ms floor for every realistic jitter estimate.
+func TestPlayoutDelayScalesWithEstimate(t *testing.T) {
+	j := &jitterEstimator{}
+
+	j.estimate = 9000 // ~100ms of jitter at the 90kHz H.264 clock rate
+	got := j.PlayoutDelay()
+	if got <= 20*time.Millisecond {
+		t.Errorf("PlayoutDelay() = %v for estimate 9000, want more than the 20ms floor", got)
+	}
+
+	j.estimate = 0
+	if got := j.PlayoutDelay(); got != 20*time.Millisecond {
+		t.Errorf("PlayoutDelay() = %v for estimate 0, want the 20ms floor", got)
+	}
+
+	j.estimate = 1e9 // absurdly large, must clamp to the ceiling
+	if got := j.PlayoutDelay(); got != 200*time.Millisecond {
+		t.Errorf("PlayoutDelay() = %v for a huge estimate, want the 200ms ceiling", got)
+	}
+}
+
+func TestJitterEstimatorUpdateTracksSteadyArrivals(t *testing.T) {
+	j := newJitterEstimator()
+	start := time.Now()
+
+	// Evenly spaced arrivals matching the RTP timestamp spacing exactly (a
+	// 33ms frame interval is 2970 ticks at the 90kHz clock rate): zero
+	// jitter, so the estimate should stay at (or very near) zero.
+	const tickPerFrame = 2970
+	for i := 0; i < 10; i++ {
+		arrival := start.Add(time.Duration(i) * 33 * time.Millisecond)
+		j.Update(uint32(i*tickPerFrame), arrival)
+	}
+	if j.estimate > 1 {
+		t.Errorf("estimate = %v after steady arrivals, want ~0", j.estimate)
+	}
+}
@@ -0,0 +1,53 @@
+package webrtc
+
+// nalAssembler reassembles NAL units out of a stream of LeweiCmd payloads.
+//
+// The drone doesn't frame NAL units to TCP packets itself (a LeweiCmd
+// payload may contain a partial unit, several units, or the tail end of a
+// unit split across reads), so units are delimited here by Annex B start
+// codes (00 00 01 / 00 00 00 01) the same way an H.264 bitstream would be.
+type nalAssembler struct {
+	buf   []byte
+	units [][]byte
+}
+
+// Write appends newly received bytes to the assembler's internal buffer.
+func (a *nalAssembler) Write(p []byte) {
+	a.buf = append(a.buf, p...)
+}
+
+// Units extracts and returns every complete NAL unit currently available,
+// leaving the last (possibly partial) unit, together with its start code,
+// buffered for the next Write.
+func (a *nalAssembler) Units() [][]byte {
+	a.units = a.units[:0]
+
+	at, ln := findStartCode(a.buf, 0)
+	if at < 0 {
+		return a.units
+	}
+	for {
+		dataStart := at + ln
+		nextAt, nextLen := findStartCode(a.buf, dataStart)
+		if nextAt < 0 {
+			a.buf = append([]byte(nil), a.buf[at:]...)
+			return a.units
+		}
+		a.units = append(a.units, a.buf[dataStart:nextAt])
+		at, ln = nextAt, nextLen
+	}
+}
+
+// findStartCode returns the index and length of the first Annex B start
+// code at or after from, or (-1, 0) if none is found.
+func findStartCode(buf []byte, from int) (at, length int) {
+	for i := from; i+3 <= len(buf); i++ {
+		if i+4 <= len(buf) && buf[i] == 0 && buf[i+1] == 0 && buf[i+2] == 0 && buf[i+3] == 1 {
+			return i, 4
+		}
+		if buf[i] == 0 && buf[i+1] == 0 && buf[i+2] == 1 {
+			return i, 3
+		}
+	}
+	return -1, 0
+}
@@ -0,0 +1,48 @@
+package webrtc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNalAssemblerUnits(t *testing.T) {
+	var a nalAssembler
+	a.Write([]byte{0, 0, 0, 1, 0xAA, 0xBB, 0, 0, 1, 0xCC, 0xDD})
+
+	units := a.Units()
+	if len(units) != 1 {
+		t.Fatalf("got %d units, want 1", len(units))
+	}
+	if !bytes.Equal(units[0], []byte{0xAA, 0xBB}) {
+		t.Errorf("unit = %x, want aabb", units[0])
+	}
+
+	// The trailing unit (started by the 3-byte start code) isn't complete
+	// yet - nothing follows it to mark its end - so it stays buffered.
+	if units := a.Units(); len(units) != 0 {
+		t.Fatalf("got %d units before the trailing unit closed, want 0", len(units))
+	}
+
+	a.Write([]byte{0, 0, 0, 1}) // closes the buffered unit
+	units = a.Units()
+	if len(units) != 1 || !bytes.Equal(units[0], []byte{0xCC, 0xDD}) {
+		t.Errorf("units = %x, want one unit ccdd", units)
+	}
+}
+
+// TestNalAssemblerSplitAcrossWrites checks that a unit split across two
+// Write calls - the common case for a NAL unit that doesn't fit in one
+// LeweiCmd payload - is still reassembled whole.
+func TestNalAssemblerSplitAcrossWrites(t *testing.T) {
+	var a nalAssembler
+	a.Write([]byte{0, 0, 1, 0x11, 0x22})
+	if units := a.Units(); len(units) != 0 {
+		t.Fatalf("got %d units before the unit closed, want 0", len(units))
+	}
+
+	a.Write([]byte{0x33, 0, 0, 0, 1, 0x44})
+	units := a.Units()
+	if len(units) != 1 || !bytes.Equal(units[0], []byte{0x11, 0x22, 0x33}) {
+		t.Errorf("units = %x, want one unit 112233", units)
+	}
+}
@@ -0,0 +1,44 @@
+package webrtc
+
+import (
+	"io"
+	"net/http"
+)
+
+// maxOfferSize bounds how much of a request body SignalingHandler will read,
+// so a misbehaving client can't exhaust memory with an oversized SDP offer.
+const maxOfferSize = 1 << 20 // 1 MiB
+
+// SignalingHandler returns an http.Handler exposing p.HandleOffer as a
+// signalling endpoint: POST the SDP offer as the request body, read back
+// the SDP answer as the response body. This is the HTTP side of the
+// offer/answer exchange HandleOffer implements in Go.
+func SignalingHandler(p *Publisher) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/offer", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "vtx: POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		offer, err := io.ReadAll(io.LimitReader(r.Body, maxOfferSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		answer, err := p.HandleOffer(string(offer))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/sdp")
+		w.Write([]byte(answer))
+	})
+	return mux
+}
+
+// ListenAndServeSignaling starts an HTTP server on addr exposing p's
+// signalling endpoint (POST /offer), so a browser can exchange SDP
+// offers/answers without a separate signalling channel.
+func ListenAndServeSignaling(addr string, p *Publisher) error {
+	return http.ListenAndServe(addr, SignalingHandler(p))
+}
@@ -0,0 +1,320 @@
+// Package webrtc bridges the drone's live H.264 video feed (delivered over
+// TCP port 7060 by command 0x0002, with frames arriving as 0x0101) to a
+// browser via WebRTC, so the feed can be watched without a custom client.
+package webrtc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+
+	"github.com/marciowb/dronio/vtx"
+)
+
+const (
+	videoPort  = 7060   // TCP port the live video stream is served on
+	streamCmd  = 0x0002 // (re)starts the video stream; also produces a keyframe
+	frameCmd   = 0x0101 // payload delivered after streamCmd
+	clockRate  = 90000  // RTP timestamp clock rate for H.264, per RFC 6184
+	maxPayload = 1200   // bytes per RTP packet before it must be split as FU-A
+)
+
+// Publisher receives the drone's H.264 feed and republishes it as a WebRTC
+// track, handling the SDP offer/answer exchange for a single browser
+// session.
+type Publisher struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	client      *vtx.Client
+	frames      <-chan []byte
+	unsubscribe func()
+	track       *webrtc.TrackLocalStaticRTP
+
+	mu          sync.Mutex
+	pc          *webrtc.PeerConnection
+	seq         uint16
+	jitter      *jitterEstimator
+	nal         nalAssembler
+	streamStart time.Time
+	warmedUp    bool
+	held        []heldUnit
+}
+
+// heldUnit is a NAL unit buffered during the initial playout-delay warmup
+// window, waiting to be released once PlayoutDelay has elapsed.
+type heldUnit struct {
+	unit      []byte
+	timestamp uint32
+}
+
+// NewWebRTCPublisher opens the drone's video stream and prepares a WebRTC
+// track to carry it. The stream is torn down when ctx is cancelled or
+// Close is called.
+func NewWebRTCPublisher(ctx context.Context) (*Publisher, error) {
+	client, err := vtx.NewClient(videoPort, vtx.ClientConfig{})
+	if err != nil {
+		return nil, fmt.Errorf("webrtc: connect video stream: %w", err)
+	}
+	frames, unsubscribe := client.Subscribe(frameCmd)
+	if err := client.Send(streamCmd, nil); err != nil {
+		unsubscribe()
+		client.Close()
+		return nil, fmt.Errorf("webrtc: start video stream: %w", err)
+	}
+
+	track, err := webrtc.NewTrackLocalStaticRTP(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264},
+		"video", "dronio",
+	)
+	if err != nil {
+		unsubscribe()
+		client.Close()
+		return nil, fmt.Errorf("webrtc: new track: %w", err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	p := &Publisher{
+		ctx:         cctx,
+		cancel:      cancel,
+		client:      client,
+		frames:      frames,
+		unsubscribe: unsubscribe,
+		track:       track,
+		jitter:      newJitterEstimator(),
+	}
+
+	go p.run()
+	go func() {
+		<-cctx.Done()
+		unsubscribe()
+		client.Close()
+	}()
+
+	return p, nil
+}
+
+// HandleOffer exchanges an SDP offer/answer pair with a browser, wiring the
+// publisher's video track and PLI/FIR RTCP feedback into the resulting
+// peer connection.
+func (p *Publisher) HandleOffer(sdp string) (answer string, err error) {
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		return "", fmt.Errorf("webrtc: new peer connection: %w", err)
+	}
+
+	if _, err := pc.AddTrack(p.track); err != nil {
+		pc.Close()
+		return "", fmt.Errorf("webrtc: add track: %w", err)
+	}
+
+	pc.OnConnectionStateChange(func(s webrtc.PeerConnectionState) {
+		if s == webrtc.PeerConnectionStateFailed || s == webrtc.PeerConnectionStateClosed {
+			p.Close()
+		}
+	})
+
+	for _, sender := range pc.GetSenders() {
+		go p.watchRTCP(sender)
+	}
+
+	if err := pc.SetRemoteDescription(webrtc.SessionDescription{
+		Type: webrtc.SDPTypeOffer,
+		SDP:  sdp,
+	}); err != nil {
+		pc.Close()
+		return "", fmt.Errorf("webrtc: set remote description: %w", err)
+	}
+
+	ans, err := pc.CreateAnswer(nil)
+	if err != nil {
+		pc.Close()
+		return "", fmt.Errorf("webrtc: create answer: %w", err)
+	}
+	if err := pc.SetLocalDescription(ans); err != nil {
+		pc.Close()
+		return "", fmt.Errorf("webrtc: set local description: %w", err)
+	}
+
+	p.mu.Lock()
+	p.pc = pc
+	p.mu.Unlock()
+
+	return ans.SDP, nil
+}
+
+// watchRTCP waits for PLI/FIR feedback from the browser and asks the drone
+// for a fresh keyframe in response, by re-issuing streamCmd on the
+// client's connection.
+func (p *Publisher) watchRTCP(sender *webrtc.RTPSender) {
+	for {
+		pkts, _, err := sender.ReadRTCP()
+		if err != nil {
+			return
+		}
+		for _, pkt := range pkts {
+			switch pkt.(type) {
+			case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+				p.client.Send(streamCmd, nil)
+			}
+		}
+	}
+}
+
+// Close stops the publisher and releases the drone connection.
+func (p *Publisher) Close() error {
+	p.cancel()
+	p.mu.Lock()
+	pc := p.pc
+	p.mu.Unlock()
+	if pc != nil {
+		return pc.Close()
+	}
+	return nil
+}
+
+// run reads frame payloads off the drone stream, reassembles NAL units and
+// writes them to the track as RTP packets until the stream ends.
+func (p *Publisher) run() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+
+		case err, ok := <-p.client.Errs():
+			if ok {
+				// A single malformed or out-of-order frame shouldn't tear
+				// down the session; drop it and keep going.
+				_ = err
+			}
+
+		case _, ok := <-p.client.Reconnected():
+			if ok {
+				// frameCmd only starts flowing again after streamCmd is
+				// re-sent on the new connection; Client transparently
+				// reconnecting underneath Subscribe would otherwise freeze
+				// the stream for good.
+				p.client.Send(streamCmd, nil)
+			}
+
+		case payload, ok := <-p.frames:
+			if !ok {
+				return
+			}
+			arrival := time.Now()
+			p.nal.Write(payload)
+			for _, unit := range p.nal.Units() {
+				p.deliver(unit, arrival)
+			}
+		}
+	}
+}
+
+// deliver holds the first units of the stream back until PlayoutDelay has
+// elapsed since the stream started, then releases them (and every unit
+// after) as soon as they arrive. This is the "adjusts the initial playout
+// delay" half of jitterEstimator: without it, the very first frames can
+// reach the browser's jitter-intolerant decoder before the network's
+// jitter has even been measured.
+func (p *Publisher) deliver(unit []byte, arrival time.Time) {
+	if len(unit) == 0 {
+		return
+	}
+	timestamp := p.rtpTimestamp(arrival)
+
+	p.mu.Lock()
+	if p.streamStart.IsZero() {
+		p.streamStart = arrival
+	}
+	if !p.warmedUp && arrival.Sub(p.streamStart) >= p.jitter.PlayoutDelay() {
+		p.warmedUp = true
+	}
+	if !p.warmedUp {
+		p.held = append(p.held, heldUnit{unit: unit, timestamp: timestamp})
+		p.mu.Unlock()
+		return
+	}
+	held := p.held
+	p.held = nil
+	p.mu.Unlock()
+
+	for _, h := range held {
+		p.writeNALU(h.unit, h.timestamp)
+	}
+	p.writeNALU(unit, timestamp)
+}
+
+// writeNALU packages a single NAL unit into one or more RTP packets
+// (a single NAL unit packet, or FU-A fragments per RFC 6184 §5.8) and
+// writes them to the track. Units are never aggregated into STAP-A
+// packets.
+func (p *Publisher) writeNALU(unit []byte, timestamp uint32) {
+	if len(unit) == 0 {
+		return
+	}
+
+	if len(unit) <= maxPayload {
+		p.writeRTP(unit, timestamp, true)
+		return
+	}
+
+	fuIndicator := (unit[0] & 0xe0) | 28 // FU-A type
+	fuHeaderType := unit[0] & 0x1f
+	payload := unit[1:]
+
+	for offset := 0; offset < len(payload); offset += maxPayload {
+		end := offset + maxPayload
+		last := end >= len(payload)
+		if last {
+			end = len(payload)
+		}
+
+		fuHeader := fuHeaderType
+		if offset == 0 {
+			fuHeader |= 0x80 // start bit
+		}
+		if last {
+			fuHeader |= 0x40 // end bit
+		}
+
+		packet := make([]byte, 0, 2+end-offset)
+		packet = append(packet, fuIndicator, fuHeader)
+		packet = append(packet, payload[offset:end]...)
+		p.writeRTP(packet, timestamp, last)
+	}
+}
+
+func (p *Publisher) writeRTP(payload []byte, timestamp uint32, marker bool) {
+	p.mu.Lock()
+	seq := p.seq
+	p.seq++
+	p.mu.Unlock()
+
+	pkt := &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			Marker:         marker,
+			PayloadType:    96, // dynamic, negotiated as H.264
+			SequenceNumber: seq,
+			Timestamp:      timestamp,
+		},
+		Payload: payload,
+	}
+	_ = p.track.WriteRTP(pkt)
+}
+
+// rtpTimestamp derives a monotonic 90 kHz RTP timestamp from the frame's
+// arrival time and feeds the arrival into the jitter estimator so
+// PlayoutDelay reflects the live stream's timing.
+func (p *Publisher) rtpTimestamp(arrival time.Time) uint32 {
+	ts := uint32(arrival.UnixNano() / int64(time.Second/clockRate))
+	p.mu.Lock()
+	p.jitter.Update(ts, arrival)
+	p.mu.Unlock()
+	return ts
+}
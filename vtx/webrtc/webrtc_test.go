@@ -0,0 +1,62 @@
+package webrtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+func newTestPublisher(t *testing.T) *Publisher {
+	t.Helper()
+	track, err := webrtc.NewTrackLocalStaticRTP(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264},
+		"video", "dronio-test",
+	)
+	if err != nil {
+		t.Fatalf("NewTrackLocalStaticRTP: %v", err)
+	}
+	return &Publisher{track: track, jitter: newJitterEstimator()}
+}
+
+// TestDeliverHoldsDuringWarmup checks that deliver buffers units until
+// PlayoutDelay has elapsed since the stream started, then releases every
+// held unit plus the one that crossed the threshold, in order.
+func TestDeliverHoldsDuringWarmup(t *testing.T) {
+	p := newTestPublisher(t)
+	// A zero jitter estimate floors PlayoutDelay at 20ms (see jitter_test.go).
+	const warmup = 20 * time.Millisecond
+
+	start := time.Now()
+	p.deliver([]byte{1}, start)
+	p.deliver([]byte{2}, start.Add(5*time.Millisecond))
+
+	p.mu.Lock()
+	held := len(p.held)
+	warmedUp := p.warmedUp
+	p.mu.Unlock()
+	if warmedUp || held != 2 {
+		t.Fatalf("after %v: warmedUp=%v held=%d, want false/2 (still buffering)", 5*time.Millisecond, warmedUp, held)
+	}
+
+	// This delivery crosses the warmup threshold, so it - and everything
+	// held before it - should be released instead of buffered further.
+	p.deliver([]byte{3}, start.Add(warmup+time.Millisecond))
+
+	p.mu.Lock()
+	held = len(p.held)
+	warmedUp = p.warmedUp
+	p.mu.Unlock()
+	if !warmedUp || held != 0 {
+		t.Fatalf("after crossing warmup: warmedUp=%v held=%d, want true/0 (flushed)", warmedUp, held)
+	}
+
+	// Once warmed up, later units are delivered straight through.
+	p.deliver([]byte{4}, start.Add(2*warmup))
+	p.mu.Lock()
+	held = len(p.held)
+	p.mu.Unlock()
+	if held != 0 {
+		t.Errorf("held = %d after warmup, want 0 (no more buffering)", held)
+	}
+}